@@ -0,0 +1,247 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// checkpointPrefix is the folder, relative to a job's DstPrefix, that
+// S3CheckpointStore writes job descriptors under.
+const checkpointPrefix = ".s3tar-checkpoint"
+
+// PartStatus records one multipart-upload part s3tar has already
+// completed, so a resumed job can skip re-issuing its UploadPartCopy.
+type PartStatus struct {
+	PartNumber int32
+	ETag       string
+}
+
+// Checkpoint is the job descriptor persisted after buildManifest computes
+// a job's deterministic per-member offsets, letting a crashed archive
+// assembly resume instead of restarting from scratch.
+type Checkpoint struct {
+	JobID          string
+	SourceListHash string
+	DstBucket      string
+	DstKey         string
+	UploadId       string
+	CompletedParts []PartStatus
+}
+
+// MissingParts returns, out of the numbered parts 1..total, those not yet
+// present in cp.CompletedParts. Resume callers should issue UploadPartCopy
+// only for these.
+func (cp *Checkpoint) MissingParts(total int32) []int32 {
+	done := make(map[int32]bool, len(cp.CompletedParts))
+	for _, p := range cp.CompletedParts {
+		done[p.PartNumber] = true
+	}
+	missing := make([]int32, 0, int(total)-len(done))
+	for n := int32(1); n <= total; n++ {
+		if !done[n] {
+			missing = append(missing, n)
+		}
+	}
+	return missing
+}
+
+// CheckpointStore persists and retrieves Checkpoints for resumable
+// archive jobs. S3CheckpointStore is the bundled S3-backed implementation.
+type CheckpointStore interface {
+	Save(ctx context.Context, cp *Checkpoint) error
+	Load(ctx context.Context, jobID string) (*Checkpoint, error)
+	List(ctx context.Context) ([]*Checkpoint, error)
+}
+
+// S3CheckpointStore persists job descriptors as JSON objects under
+// Prefix/.s3tar-checkpoint/<jobid>.json in Bucket.
+type S3CheckpointStore struct {
+	Client *s3.Client
+	Opts   *S3TarS3Options
+	Bucket string
+	Prefix string
+}
+
+func (s *S3CheckpointStore) keyFor(jobID string) string {
+	return strings.TrimRight(s.Prefix, "/") + "/" + checkpointPrefix + "/" + jobID + ".json"
+}
+
+func (s *S3CheckpointStore) Save(ctx context.Context, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("s3tar: marshaling checkpoint %s: %w", cp.JobID, err)
+	}
+	_, err = putObject(ctx, s.Client, s.Opts, s.Bucket, s.keyFor(cp.JobID), data)
+	return err
+}
+
+func (s *S3CheckpointStore) Load(ctx context.Context, jobID string) (*Checkpoint, error) {
+	rc, err := getObject(ctx, s.Client, s.Opts, s.Bucket, s.keyFor(jobID))
+	if err != nil {
+		return nil, fmt.Errorf("s3tar: loading checkpoint %s: %w", jobID, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("s3tar: unmarshaling checkpoint %s: %w", jobID, err)
+	}
+	return cp, nil
+}
+
+func (s *S3CheckpointStore) List(ctx context.Context) ([]*Checkpoint, error) {
+	prefix := strings.TrimRight(s.Prefix, "/") + "/" + checkpointPrefix + "/"
+	objects := listAllObjects(ctx, s.Client, s.Opts, s.Bucket, prefix)
+
+	checkpoints := make([]*Checkpoint, 0, len(objects))
+	for _, obj := range objects {
+		rc, err := getObject(ctx, s.Client, s.Opts, s.Bucket, aws.ToString(obj.Key))
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		cp := &Checkpoint{}
+		if err := json.Unmarshal(data, cp); err != nil {
+			return nil, fmt.Errorf("s3tar: unmarshaling checkpoint %s: %w", aws.ToString(obj.Key), err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+// hashSourceList fingerprints a member list's keys, ETags and sizes so a
+// Resume can detect that the source objects changed underneath a job.
+func hashSourceList(objectList []*S3Obj) string {
+	h := sha256.New()
+	for _, o := range objectList {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\n", aws.ToString(o.Key), aws.ToString(o.ETag), o.Size)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SaveCheckpoint persists a job descriptor for a newly started or
+// progressing archive assembly. It is a no-op when opts doesn't configure
+// a CheckpointStore and JobID, so callers can unconditionally invoke it
+// once buildManifest has computed the archive's per-member offsets.
+func SaveCheckpoint(ctx context.Context, opts *S3TarS3Options, objectList []*S3Obj, uploadID string, completed []PartStatus) error {
+	if opts == nil || opts.CheckpointStore == nil || opts.JobID == "" {
+		return nil
+	}
+	cp := &Checkpoint{
+		JobID:          opts.JobID,
+		SourceListHash: hashSourceList(objectList),
+		DstBucket:      opts.DstBucket,
+		DstKey:         opts.DstKey,
+		UploadId:       uploadID,
+		CompletedParts: completed,
+	}
+	return opts.CheckpointStore.Save(ctx, cp)
+}
+
+// ErrSourceListChanged is returned by Resume when the source objects
+// passed in no longer match the ones a checkpointed job was built from,
+// so the caller doesn't complete a multipart upload stitched together
+// from members that have since changed or been deleted underneath it.
+var ErrSourceListChanged = errors.New("s3tar: source object list has changed since the checkpoint was saved")
+
+// Resume loads the checkpoint for jobID, verifies objectList still
+// hashes to the same SourceListHash the checkpoint was saved with
+// (returning ErrSourceListChanged if not), and reconciles it against the
+// destination multipart upload's actual parts via ListParts, dropping any
+// completed part whose ETag no longer matches (or that S3 no longer has).
+// The returned Checkpoint's CompletedParts reflects only parts the caller
+// can safely skip; MissingParts tells it which ones still need a
+// UploadPartCopy.
+func Resume(ctx context.Context, opts *S3TarS3Options, jobID string, objectList []*S3Obj) (*Checkpoint, error) {
+	if opts == nil || opts.CheckpointStore == nil {
+		return nil, fmt.Errorf("s3tar: Resume requires S3TarS3Options.CheckpointStore")
+	}
+	cp, err := opts.CheckpointStore.Load(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if cp.SourceListHash != hashSourceList(objectList) {
+		return nil, fmt.Errorf("%w: job %s", ErrSourceListChanged, jobID)
+	}
+
+	client := GetS3Client(ctx)
+	if err := reconcileCheckpoint(ctx, client, opts, cp); err != nil {
+		return nil, fmt.Errorf("s3tar: reconciling checkpoint %s: %w", jobID, err)
+	}
+	return cp, nil
+}
+
+func reconcileCheckpoint(ctx context.Context, client *s3.Client, opts *S3TarS3Options, cp *Checkpoint) error {
+	actual := make(map[int32]string)
+	p := s3.NewListPartsPaginator(client, &s3.ListPartsInput{
+		Bucket:   &cp.DstBucket,
+		Key:      &cp.DstKey,
+		UploadId: &cp.UploadId,
+	})
+	for p.HasMorePages() {
+		start := time.Now()
+		var page *s3.ListPartsOutput
+		err := withRetry(ctx, opts, "ListParts", func(attemptCtx context.Context) error {
+			var pageErr error
+			page, pageErr = p.NextPage(attemptCtx)
+			return pageErr
+		})
+		metricsFor(opts).observe("ListParts", start, 0, err)
+		if err != nil {
+			return err
+		}
+		for _, part := range page.Parts {
+			actual[aws.ToInt32(part.PartNumber)] = aws.ToString(part.ETag)
+		}
+	}
+
+	reconciled := make([]PartStatus, 0, len(cp.CompletedParts))
+	for _, part := range cp.CompletedParts {
+		if etag, ok := actual[part.PartNumber]; ok && etag == part.ETag {
+			reconciled = append(reconciled, part)
+		}
+	}
+	cp.CompletedParts = reconciled
+	return nil
+}
+
+// checkpointOwnedUploadIDs returns the set of in-progress multipart
+// UploadIds that a resumable job in store still owns for bucket, so
+// DeleteAllMultiparts can leave them alone.
+func checkpointOwnedUploadIDs(ctx context.Context, store CheckpointStore, bucket string) (map[string]bool, error) {
+	owned := map[string]bool{}
+	if store == nil {
+		return owned, nil
+	}
+	checkpoints, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range checkpoints {
+		if cp.DstBucket == bucket && cp.UploadId != "" {
+			owned[cp.UploadId] = true
+		}
+	}
+	return owned, nil
+}
@@ -0,0 +1,130 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestCheckpointMissingParts(t *testing.T) {
+	cp := &Checkpoint{CompletedParts: []PartStatus{
+		{PartNumber: 1, ETag: "etag-1"},
+		{PartNumber: 3, ETag: "etag-3"},
+	}}
+
+	got := cp.MissingParts(4)
+	want := []int32{2, 4}
+	if len(got) != len(want) {
+		t.Fatalf("MissingParts(4) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("MissingParts(4)[%d] = %d, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCheckpointMissingPartsAllDone(t *testing.T) {
+	cp := &Checkpoint{CompletedParts: []PartStatus{
+		{PartNumber: 1, ETag: "etag-1"},
+		{PartNumber: 2, ETag: "etag-2"},
+	}}
+	if got := cp.MissingParts(2); len(got) != 0 {
+		t.Errorf("MissingParts(2) = %v, want empty", got)
+	}
+}
+
+// testS3Client builds a client talking to a local httptest.Server instead
+// of real S3, mirroring how client.go's NewS3Client builds one against
+// real AWS but pointed at srv.URL.
+func testS3Client(srv *httptest.Server) *s3.Client {
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(srv.URL)
+		o.UsePathStyle = true
+	})
+}
+
+// listPartsXML renders a single-page ListParts response naming the given
+// part numbers and ETags.
+func listPartsXML(parts map[int32]string) string {
+	body := `<?xml version="1.0" encoding="UTF-8"?><ListPartsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`
+	body += `<Bucket>dst-bucket</Bucket><Key>dst-key</Key><UploadId>upload-1</UploadId><IsTruncated>false</IsTruncated>`
+	for n, etag := range parts {
+		body += fmt.Sprintf(`<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>`, n, etag)
+	}
+	body += `</ListPartsResult>`
+	return body
+}
+
+func TestReconcileCheckpointDropsStaleParts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		// Only part 1 still matches what's actually on S3; part 2's ETag
+		// has since changed, and part 3 doesn't exist anymore.
+		fmt.Fprint(w, listPartsXML(map[int32]string{1: "etag-1", 2: "etag-2-changed"}))
+	}))
+	defer srv.Close()
+
+	cp := &Checkpoint{
+		DstBucket: "dst-bucket",
+		DstKey:    "dst-key",
+		UploadId:  "upload-1",
+		CompletedParts: []PartStatus{
+			{PartNumber: 1, ETag: "etag-1"},
+			{PartNumber: 2, ETag: "etag-2"},
+			{PartNumber: 3, ETag: "etag-3"},
+		},
+	}
+
+	if err := reconcileCheckpoint(context.Background(), testS3Client(srv), nil, cp); err != nil {
+		t.Fatalf("reconcileCheckpoint: %v", err)
+	}
+	if len(cp.CompletedParts) != 1 || cp.CompletedParts[0].PartNumber != 1 {
+		t.Errorf("CompletedParts = %+v, want only part 1", cp.CompletedParts)
+	}
+}
+
+func TestReconcileCheckpointRetriesTransientFailure(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, listPartsXML(map[int32]string{1: "etag-1"}))
+	}))
+	defer srv.Close()
+
+	cp := &Checkpoint{
+		DstBucket:      "dst-bucket",
+		DstKey:         "dst-key",
+		UploadId:       "upload-1",
+		CompletedParts: []PartStatus{{PartNumber: 1, ETag: "etag-1"}},
+	}
+	opts := &S3TarS3Options{InitialBackoff: 0, MaxBackoff: 0}
+
+	if err := reconcileCheckpoint(context.Background(), testS3Client(srv), opts, cp); err != nil {
+		t.Fatalf("reconcileCheckpoint: %v", err)
+	}
+	if attempts < 2 {
+		t.Errorf("attempts = %d, want at least 2 (a retry after the first ServiceUnavailable)", attempts)
+	}
+	if len(cp.CompletedParts) != 1 {
+		t.Errorf("CompletedParts = %+v, want part 1 to survive reconciliation", cp.CompletedParts)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/crc64"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ChecksumAlgorithm selects one of S3's additional full-object checksum
+// algorithms, matching its own x-amz-checksum-* feature.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumNone      ChecksumAlgorithm = ""
+	ChecksumSHA256    ChecksumAlgorithm = "SHA256"
+	ChecksumCRC32C    ChecksumAlgorithm = "CRC32C"
+	ChecksumCRC64NVME ChecksumAlgorithm = "CRC64NVME"
+)
+
+// crc64NVMETable is the reflected CRC-64/NVME polynomial S3 uses for its
+// x-amz-checksum-crc64nvme header.
+var crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// computeChecksum returns the base64 encoding of data's checksum under
+// algo, matching the value S3 expects in the corresponding
+// x-amz-checksum-* header. It returns "" for ChecksumNone.
+func computeChecksum(data []byte, algo ChecksumAlgorithm) string {
+	switch algo {
+	case ChecksumSHA256:
+		sum := sha256.Sum256(data)
+		return base64.StdEncoding.EncodeToString(sum[:])
+	case ChecksumCRC32C:
+		sum := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, sum)
+		return base64.StdEncoding.EncodeToString(b)
+	case ChecksumCRC64NVME:
+		sum := crc64.Checksum(data, crc64NVMETable)
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, sum)
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return ""
+	}
+}
+
+// sdkChecksumAlgorithm maps a ChecksumAlgorithm to the aws-sdk-go-v2 S3
+// type of the same name.
+func sdkChecksumAlgorithm(algo ChecksumAlgorithm) types.ChecksumAlgorithm {
+	switch algo {
+	case ChecksumSHA256:
+		return types.ChecksumAlgorithmSha256
+	case ChecksumCRC32C:
+		return types.ChecksumAlgorithmCrc32c
+	case ChecksumCRC64NVME:
+		return types.ChecksumAlgorithmCrc64nvme
+	default:
+		return ""
+	}
+}
+
+// checksumAlgorithmFor returns opts.ChecksumAlgorithm, tolerating a nil
+// opts, so callers that only have opts need not nil-check it themselves.
+func checksumAlgorithmFor(opts *S3TarS3Options) ChecksumAlgorithm {
+	if opts == nil {
+		return ChecksumNone
+	}
+	return opts.ChecksumAlgorithm
+}
+
+// applyChecksumAlgorithm tells S3 which additional checksum to compute
+// and verify for the destination archive and manifest, so the assembled
+// object carries a verifiable full-object checksum.
+func applyChecksumAlgorithm(input any, opts *S3TarS3Options) {
+	if opts == nil || opts.ChecksumAlgorithm == ChecksumNone {
+		return
+	}
+	algo := sdkChecksumAlgorithm(opts.ChecksumAlgorithm)
+	switch v := input.(type) {
+	case *s3.PutObjectInput:
+		v.ChecksumAlgorithm = algo
+	case *s3.CreateMultipartUploadInput:
+		v.ChecksumAlgorithm = algo
+	}
+}
+
+// AddDataWithChecksum is AddData plus recording data's checksum under
+// algo (as set by S3TarS3Options.ChecksumAlgorithm) on s.Checksum, so it
+// can be carried into the manifest alongside the ETag.
+func (s *S3Obj) AddDataWithChecksum(data []byte, algo ChecksumAlgorithm) {
+	s.AddData(data)
+	s.Checksum = computeChecksum(data, algo)
+}
+
+// headChecksum returns obj's own x-amz-checksum-* value for algo, as
+// already stored by S3, via a HeadObject with ChecksumMode enabled. It
+// returns "" (not an error) when the object has no checksum of that
+// algorithm recorded, which is the common case for objects uploaded
+// before ChecksumAlgorithm was set or uploaded without one at all.
+func headChecksum(ctx context.Context, client *s3.Client, opts *S3TarS3Options, obj *S3Obj, algo ChecksumAlgorithm) (string, error) {
+	start := time.Now()
+	params := &s3.HeadObjectInput{
+		Bucket:       &obj.Bucket,
+		Key:          obj.Key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	applySrcSSECustomerKey(params, opts)
+
+	var output *s3.HeadObjectOutput
+	err := withRetry(ctx, opts, "HeadObject", func(attemptCtx context.Context) error {
+		var headErr error
+		output, headErr = client.HeadObject(attemptCtx, params)
+		return headErr
+	})
+	metricsFor(opts).observe("HeadObject", start, 0, err)
+	if err != nil {
+		return "", err
+	}
+	switch algo {
+	case ChecksumSHA256:
+		return aws.ToString(output.ChecksumSHA256), nil
+	case ChecksumCRC32C:
+		return aws.ToString(output.ChecksumCRC32C), nil
+	case ChecksumCRC64NVME:
+		return aws.ToString(output.ChecksumCRC64NVME), nil
+	default:
+		return "", nil
+	}
+}
+
+// defaultChecksumConcurrency bounds ensureMemberChecksums' fan-out when
+// opts is nil or opts.Threads is unset.
+const defaultChecksumConcurrency = 16
+
+// checksumConcurrencyFor returns opts.Threads, tolerating a nil opts or
+// an unset Threads, in which case it returns defaultChecksumConcurrency.
+func checksumConcurrencyFor(opts *S3TarS3Options) int {
+	if opts != nil && opts.Threads > 0 {
+		return int(opts.Threads)
+	}
+	return defaultChecksumConcurrency
+}
+
+// ensureMemberChecksums fills in Checksum on every member of objectList
+// that doesn't already carry one, so buildManifest's entries carry a real
+// per-member checksum instead of "" whenever opts.ChecksumAlgorithm is
+// set. It only resolves an S3 client (via GetS3Client) when there's
+// actually a checksum algorithm configured, so a plain ctx works fine for
+// the common ChecksumNone case.
+func ensureMemberChecksums(ctx context.Context, opts *S3TarS3Options, objectList []*S3Obj) error {
+	if checksumAlgorithmFor(opts) == ChecksumNone {
+		return nil
+	}
+	return ensureMemberChecksumsWithClient(ctx, GetS3Client(ctx), opts, objectList)
+}
+
+// ensureMemberChecksumsWithClient is ensureMemberChecksums with the S3
+// client passed in, so tests can exercise the HeadObject/fan-out logic
+// against a fake client without going through GetS3Client(ctx). It reads
+// each missing checksum back from S3 via headChecksum rather than
+// downloading and recomputing, so it stays cheap even for an archive
+// assembled entirely from UploadPartCopy; members that predate checksum
+// support, or were uploaded without one, simply keep Checksum == "". It
+// fans out up to opts.Threads HeadObject calls at once, since a large
+// archive can have thousands of members and each call is independent. A
+// HeadObject failure (after retries) is returned rather than swallowed,
+// so a member left at Checksum == "" because of a real error isn't
+// mistaken for one that simply predates checksum support.
+func ensureMemberChecksumsWithClient(ctx context.Context, client *s3.Client, opts *S3TarS3Options, objectList []*S3Obj) error {
+	algo := checksumAlgorithmFor(opts)
+	if algo == ChecksumNone {
+		return nil
+	}
+
+	sem := make(chan struct{}, checksumConcurrencyFor(opts))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for _, obj := range objectList {
+		if obj.Checksum != "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(obj *S3Obj) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			checksum, err := headChecksum(ctx, client, opts, obj, algo)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("s3tar: member checksum for %s/%s: %w", obj.Bucket, aws.ToString(obj.Key), err)
+				}
+				mu.Unlock()
+				return
+			}
+			obj.Checksum = checksum
+		}(obj)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,144 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestComputeChecksumNone(t *testing.T) {
+	if got := computeChecksum([]byte("hello"), ChecksumNone); got != "" {
+		t.Errorf("computeChecksum(ChecksumNone) = %q, want empty", got)
+	}
+}
+
+func TestComputeChecksumSHA256(t *testing.T) {
+	// Known value: base64(SHA-256("")).
+	want := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	if got := computeChecksum(nil, ChecksumSHA256); got != want {
+		t.Errorf("computeChecksum(ChecksumSHA256, \"\") = %q, want %q", got, want)
+	}
+}
+
+func TestComputeChecksumCRC32C(t *testing.T) {
+	// Known CRC-32C ("Castagnoli") check value for the ASCII string
+	// "123456789" is 0xE3069283.
+	want := base64.StdEncoding.EncodeToString([]byte{0xE3, 0x06, 0x92, 0x83})
+	if got := computeChecksum([]byte("123456789"), ChecksumCRC32C); got != want {
+		t.Errorf("computeChecksum(ChecksumCRC32C, %q) = %q, want %q", "123456789", got, want)
+	}
+}
+
+func TestComputeChecksumCRC64NVMEIsStableAndSensitive(t *testing.T) {
+	a := computeChecksum([]byte("s3tar"), ChecksumCRC64NVME)
+	again := computeChecksum([]byte("s3tar"), ChecksumCRC64NVME)
+	if a != again {
+		t.Errorf("computeChecksum(ChecksumCRC64NVME) is not deterministic: %q != %q", a, again)
+	}
+	if b := computeChecksum([]byte("s3tar!"), ChecksumCRC64NVME); a == b {
+		t.Errorf("computeChecksum(ChecksumCRC64NVME) gave the same value for different input: %q", a)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(a)
+	if err != nil {
+		t.Fatalf("decoding checksum: %v", err)
+	}
+	if len(decoded) != 8 {
+		t.Errorf("decoded CRC64NVME checksum is %d bytes, want 8", len(decoded))
+	}
+}
+
+func TestAddDataWithChecksum(t *testing.T) {
+	obj := NewS3Obj()
+	data := []byte("archive contents")
+	obj.AddDataWithChecksum(data, ChecksumSHA256)
+
+	if obj.Size != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", obj.Size, len(data))
+	}
+	want := computeChecksum(data, ChecksumSHA256)
+	if obj.Checksum != want {
+		t.Errorf("Checksum = %q, want %q", obj.Checksum, want)
+	}
+}
+
+func TestEnsureMemberChecksumsReadsExistingAndFansOut(t *testing.T) {
+	const n = 8
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amz-checksum-sha256", "precomputed-"+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	objectList := make([]*S3Obj, n)
+	for i := range objectList {
+		obj := NewS3Obj()
+		key := fmt.Sprintf("member-%d.txt", i)
+		obj.Bucket = "src-bucket"
+		obj.Key = &key
+		objectList[i] = obj
+	}
+	// One member already has a checksum from AddDataWithChecksum; it
+	// should be left untouched rather than re-fetched from S3.
+	objectList[0].Checksum = "already-set"
+
+	client := testS3Client(srv)
+	opts := &S3TarS3Options{ChecksumAlgorithm: ChecksumSHA256, Threads: 4}
+	if err := ensureMemberChecksumsWithClient(context.Background(), client, opts, objectList); err != nil {
+		t.Fatalf("ensureMemberChecksumsWithClient: %v", err)
+	}
+
+	if objectList[0].Checksum != "already-set" {
+		t.Errorf("objectList[0].Checksum = %q, want unchanged %q", objectList[0].Checksum, "already-set")
+	}
+	for i := 1; i < n; i++ {
+		// UsePathStyle puts the bucket in the request path too.
+		want := "precomputed-/" + objectList[i].Bucket + "/" + *objectList[i].Key
+		if objectList[i].Checksum != want {
+			t.Errorf("objectList[%d].Checksum = %q, want %q", i, objectList[i].Checksum, want)
+		}
+	}
+}
+
+func TestEnsureMemberChecksumsNoopWithoutAlgorithm(t *testing.T) {
+	obj := NewS3Obj()
+	key := "member.txt"
+	obj.Bucket, obj.Key = "src-bucket", &key
+	objectList := []*S3Obj{obj}
+
+	// No HTTP server at all: a nil/none algorithm must never make an S3
+	// call, let alone dereference a client.
+	if err := ensureMemberChecksumsWithClient(context.Background(), nil, &S3TarS3Options{}, objectList); err != nil {
+		t.Fatalf("ensureMemberChecksumsWithClient: %v", err)
+	}
+	if objectList[0].Checksum != "" {
+		t.Errorf("Checksum = %q, want empty (ChecksumNone must short-circuit before touching the client)", objectList[0].Checksum)
+	}
+}
+
+func TestEnsureMemberChecksumsReturnsErrorOnHeadObjectFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	key := "member.txt"
+	obj := NewS3Obj()
+	obj.Bucket, obj.Key = "src-bucket", &key
+	objectList := []*S3Obj{obj}
+
+	opts := &S3TarS3Options{ChecksumAlgorithm: ChecksumSHA256, MaxAttempts: 1}
+	err := ensureMemberChecksumsWithClient(context.Background(), testS3Client(srv), opts, objectList)
+	if err == nil {
+		t.Fatal("ensureMemberChecksumsWithClient: want error on a HeadObject failure, got nil")
+	}
+	if objectList[0].Checksum != "" {
+		t.Errorf("Checksum = %q, want empty on failure rather than a silently accepted value", objectList[0].Checksum)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultConnectTimeout is used when opts is nil or its ConnectTimeout is
+// left at zero.
+const defaultConnectTimeout = 10 * time.Second
+
+// NewS3Client builds an *s3.Client from cfg whose transport's dial
+// timeout is bound by opts.ConnectTimeout, so a stalled TCP/TLS handshake
+// can't hang an hours-long archive assembly. Build client sessions with
+// this instead of s3.NewFromConfig directly to make ConnectTimeout take
+// effect.
+func NewS3Client(cfg aws.Config, opts *S3TarS3Options) *s3.Client {
+	connectTimeout := defaultConnectTimeout
+	if opts != nil && opts.ConnectTimeout > 0 {
+		connectTimeout = opts.ConnectTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout}).DialContext
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.HTTPClient = &http.Client{Transport: transport}
+	})
+}
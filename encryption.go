@@ -0,0 +1,124 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrMixedSSECSources is returned when an UploadPartCopy would stitch
+// together source objects that were encrypted with different SSE-C keys.
+// S3 cannot decrypt a copy source with the destination's customer key, so
+// every source touched by a single part must share the same SSE-C key.
+var ErrMixedSSECSources = errors.New("s3tar: cannot UploadPartCopy across sources encrypted with different SSE-C keys")
+
+// validateEncryptionOptions checks the destination/source encryption
+// settings on opts for combinations S3 itself would reject.
+func validateEncryptionOptions(opts *S3TarS3Options) error {
+	if opts == nil {
+		return nil
+	}
+	if opts.DstSSECustomerKey != "" && opts.DstSSEType != "" {
+		return errors.New("s3tar: DstSSECustomerKey and DstSSEType are mutually exclusive")
+	}
+	if opts.DstSSEType == types.ServerSideEncryptionAwsKms && opts.DstSSEKMSKeyId == "" {
+		return errors.New("s3tar: DstSSEKMSKeyId is required when DstSSEType is aws:kms")
+	}
+	return nil
+}
+
+// validateUploadPartCopySources rejects assembling a single part out of
+// source objects protected by different SSE-C keys, since the destination
+// can only present one x-amz-copy-source-server-side-encryption-customer-key
+// per UploadPartCopy call.
+func validateUploadPartCopySources(keys ...string) error {
+	var first string
+	for i, k := range keys {
+		if i == 0 {
+			first = k
+			continue
+		}
+		if k != first {
+			return ErrMixedSSECSources
+		}
+	}
+	return nil
+}
+
+// applyDstEncryption sets the destination storage class and encryption
+// headers shared by PutObject, CreateMultipartUpload, and UploadPartCopy
+// requests that write the assembled archive and its manifest.
+func applyDstEncryption(input any, opts *S3TarS3Options) {
+	if opts == nil {
+		return
+	}
+	switch v := input.(type) {
+	case *s3.PutObjectInput:
+		if opts.DstStorageClass != "" {
+			v.StorageClass = opts.DstStorageClass
+		}
+		v.ServerSideEncryption = opts.DstSSEType
+		if opts.DstSSEKMSKeyId != "" {
+			v.SSEKMSKeyId = aws.String(opts.DstSSEKMSKeyId)
+		}
+		if opts.DstSSEKMSEncryptionContext != "" {
+			v.SSEKMSEncryptionContext = aws.String(opts.DstSSEKMSEncryptionContext)
+		}
+		if opts.DstSSECustomerKey != "" {
+			v.SSECustomerAlgorithm = aws.String(opts.DstSSECustomerAlgorithm)
+			v.SSECustomerKey = aws.String(opts.DstSSECustomerKey)
+			v.SSECustomerKeyMD5 = aws.String(opts.DstSSECustomerKeyMD5)
+		}
+	case *s3.CreateMultipartUploadInput:
+		if opts.DstStorageClass != "" {
+			v.StorageClass = opts.DstStorageClass
+		}
+		v.ServerSideEncryption = opts.DstSSEType
+		if opts.DstSSEKMSKeyId != "" {
+			v.SSEKMSKeyId = aws.String(opts.DstSSEKMSKeyId)
+		}
+		if opts.DstSSEKMSEncryptionContext != "" {
+			v.SSEKMSEncryptionContext = aws.String(opts.DstSSEKMSEncryptionContext)
+		}
+		if opts.DstSSECustomerKey != "" {
+			v.SSECustomerAlgorithm = aws.String(opts.DstSSECustomerAlgorithm)
+			v.SSECustomerKey = aws.String(opts.DstSSECustomerKey)
+			v.SSECustomerKeyMD5 = aws.String(opts.DstSSECustomerKeyMD5)
+		}
+	case *s3.UploadPartCopyInput:
+		if opts.DstSSECustomerKey != "" {
+			v.SSECustomerAlgorithm = aws.String(opts.DstSSECustomerAlgorithm)
+			v.SSECustomerKey = aws.String(opts.DstSSECustomerKey)
+			v.SSECustomerKeyMD5 = aws.String(opts.DstSSECustomerKeyMD5)
+		}
+		if opts.SrcSSECustomerKey != "" {
+			v.CopySourceSSECustomerAlgorithm = aws.String(opts.SrcSSECustomerAlgorithm)
+			v.CopySourceSSECustomerKey = aws.String(opts.SrcSSECustomerKey)
+			v.CopySourceSSECustomerKeyMD5 = aws.String(opts.SrcSSECustomerKeyMD5)
+		}
+	}
+}
+
+// applySrcSSECustomerKey attaches the SSE-C key needed to read back an
+// intermediate part (or its metadata) that was uploaded with
+// customer-provided encryption.
+func applySrcSSECustomerKey(params any, opts *S3TarS3Options) {
+	if opts == nil || opts.SrcSSECustomerKey == "" {
+		return
+	}
+	switch v := params.(type) {
+	case *s3.GetObjectInput:
+		v.SSECustomerAlgorithm = aws.String(opts.SrcSSECustomerAlgorithm)
+		v.SSECustomerKey = aws.String(opts.SrcSSECustomerKey)
+		v.SSECustomerKeyMD5 = aws.String(opts.SrcSSECustomerKeyMD5)
+	case *s3.HeadObjectInput:
+		v.SSECustomerAlgorithm = aws.String(opts.SrcSSECustomerAlgorithm)
+		v.SSECustomerKey = aws.String(opts.SrcSSECustomerKey)
+		v.SSECustomerKeyMD5 = aws.String(opts.SrcSSECustomerKeyMD5)
+	}
+}
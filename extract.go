@@ -0,0 +1,336 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// copyPartThreshold is the size at or above which ExtractOne copies a member
+// to another S3 object with UploadPartCopy instead of downloading and
+// re-uploading it.
+const copyPartThreshold = 5 * 1024 * 1024
+
+// Entry is one row of an archive's manifest: a member's name, its byte
+// range within the assembled tar, and its ETag/checksum.
+type Entry = ManifestEntry
+
+// Reader serves random access to the members of an archive built by
+// s3tar, using the archive's manifest table of contents instead of scanning
+// 512-byte tar headers.
+type Reader struct {
+	ctx    context.Context
+	client *s3.Client
+	opts   *S3TarS3Options
+	bucket string
+	key    string
+
+	data  []byte
+	codec ManifestCodec
+	// order caches codec.Decode(data), filled in lazily by entries() the
+	// first time a caller needs every member at once (List, ExtractAll).
+	// Open/ExtractOne go through codec.Lookup instead, so a single-member
+	// read of a sorted-index manifest builds only the matching entry; CSV
+	// and NDJSON lookups still decode the whole table of contents.
+	order []Entry
+}
+
+// OpenReader builds a Reader for the archive at s3url (an s3://bucket/key
+// URL), indexing it from opts.ExternalToc if set, or from the manifest
+// (manifest.csv, manifest.ndjson or manifest.idx) embedded as the
+// archive's first tar member otherwise. It only fetches
+// the raw manifest bytes; it doesn't decode them until a caller asks for
+// a specific member or the full listing.
+func OpenReader(ctx context.Context, client *s3.Client, opts *S3TarS3Options, s3url string) (*Reader, error) {
+	bucket, key := ExtractBucketAndPath(s3url)
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("s3tar: %q is not a valid s3:// archive URL", s3url)
+	}
+
+	data, codec, err := loadManifestData(ctx, client, opts, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		ctx:    ctx,
+		client: client,
+		opts:   opts,
+		bucket: bucket,
+		key:    key,
+		data:   data,
+		codec:  codec,
+	}, nil
+}
+
+// entries decodes and caches the full manifest, in original (tar-member)
+// order, for callers that need every member at once.
+func (r *Reader) entries() ([]Entry, error) {
+	if r.order == nil {
+		entries, err := r.codec.Decode(r.data)
+		if err != nil {
+			return nil, err
+		}
+		r.order = entries
+	}
+	return r.order, nil
+}
+
+// loadManifestData fetches the raw manifest bytes and the codec that can
+// decode them, either from opts.ExternalToc or from the archive's
+// embedded first member. The member name (manifest.csv/.ndjson/.idx)
+// picks the codec when reading the embedded manifest; ExternalToc instead
+// trusts opts.ManifestFormat, since its file name is caller-chosen.
+func loadManifestData(ctx context.Context, client *s3.Client, opts *S3TarS3Options, bucket, archiveKey string) ([]byte, ManifestCodec, error) {
+	if opts != nil && opts.ExternalToc != "" {
+		rc, err := loadFile(ctx, client, opts, opts.ExternalToc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("s3tar: reading external TOC %s: %w", opts.ExternalToc, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, codecForFormat(manifestFormatFor(opts)), nil
+	}
+
+	rc, err := getObject(ctx, client, opts, bucket, archiveKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("s3tar: opening archive s3://%s/%s: %w", bucket, archiveKey, err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("s3tar: reading embedded manifest header: %w", err)
+	}
+
+	var codec ManifestCodec
+	switch hdr.Name {
+	case "manifest.csv":
+		codec = codecForFormat(ManifestFormatCSV)
+	case "manifest.ndjson":
+		codec = codecForFormat(ManifestFormatNDJSON)
+	case "manifest.idx":
+		codec = codecForFormat(ManifestFormatSortedIndex)
+	default:
+		return nil, nil, fmt.Errorf("s3tar: expected a manifest as the first tar member, got %q", hdr.Name)
+	}
+
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, codec, nil
+}
+
+// List returns every member indexed from the manifest, in manifest order
+// (tar-member order, for every ManifestFormat - sorted-index manifests
+// are stored key-sorted on disk but decoded back into their original
+// order).
+func (r *Reader) List() ([]Entry, error) {
+	entries, err := r.entries()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Entry, len(entries))
+	copy(out, entries)
+	return out, nil
+}
+
+// Open serves a member by issuing a ranged GetObject for exactly
+// [offset, offset+size-1] within the archive, skipping tar header
+// parsing entirely. It looks the member up via codec.Lookup: for
+// ManifestFormatSortedIndex that is a binary search over the sorted
+// records that builds only the matching entry (the fixed-width record
+// array is still read in full first); CSV and NDJSON manifests are
+// decoded in full and scanned.
+func (r *Reader) Open(name string) (io.ReadCloser, error) {
+	e, ok, err := r.codec.Lookup(r.data, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("s3tar: member %q not found in manifest", name)
+	}
+	if e.Size == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return getObjectRange(r.ctx, r.client, r.opts, r.bucket, r.key, e.Offset, e.Offset+e.Size-1)
+}
+
+// ExtractAll copies every member whose name has the given prefix out of
+// the archive, writing each to dstDir/<name> (for a local dstDir) or
+// s3://bucket/dstDir/<name> (for an s3:// dstDir).
+func (r *Reader) ExtractAll(ctx context.Context, prefix, dstDir string) error {
+	entries, err := r.entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+		dst := strings.TrimRight(dstDir, "/") + "/" + e.Key
+		if err := r.ExtractOne(ctx, e.Key, dst); err != nil {
+			return fmt.Errorf("s3tar: extracting %q: %w", e.Key, err)
+		}
+	}
+	return nil
+}
+
+// ExtractOne copies a single member back out of the archive to dst. When
+// dst is an s3:// URL and the member is at least copyPartThreshold bytes,
+// it uses UploadPartCopy against the original archive object, avoiding a
+// download/upload round trip; smaller members and local destinations are
+// read with a ranged GetObject and written directly (S3's CopyObject has
+// no byte-range support, so it can't be used for a sub-object copy).
+func (r *Reader) ExtractOne(ctx context.Context, name, dst string) error {
+	e, ok, err := r.codec.Lookup(r.data, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("s3tar: member %q not found in manifest", name)
+	}
+
+	if strings.Contains(dst, "s3://") {
+		dstBucket, dstKey := ExtractBucketAndPath(dst)
+		if e.Size >= copyPartThreshold {
+			return r.copyRangeViaMultipart(ctx, e, dstBucket, dstKey)
+		}
+		return r.copyRangeToS3(ctx, e, dstBucket, dstKey)
+	}
+	return r.copyRangeToFile(ctx, e, dst)
+}
+
+func (r *Reader) copyRangeToFile(ctx context.Context, e Entry, dst string) error {
+	body, err := getObjectRange(ctx, r.client, r.opts, r.bucket, r.key, e.Offset, e.Offset+e.Size-1)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, body)
+	return err
+}
+
+func (r *Reader) copyRangeToS3(ctx context.Context, e Entry, dstBucket, dstKey string) error {
+	body, err := getObjectRange(ctx, r.client, r.opts, r.bucket, r.key, e.Offset, e.Offset+e.Size-1)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	_, err = putObject(ctx, r.client, r.opts, dstBucket, dstKey, data)
+	return err
+}
+
+func (r *Reader) copyRangeViaMultipart(ctx context.Context, e Entry, dstBucket, dstKey string) error {
+	if err := validateEncryptionOptions(r.opts); err != nil {
+		return err
+	}
+	// This part's only copy source is the archive object itself
+	// (r.bucket/r.key), so there's only ever one SSE-C key to check; the
+	// call stays here so the guard also protects a future caller that
+	// stitches a single destination part from more than one archive.
+	var srcSSECustomerKey string
+	if r.opts != nil {
+		srcSSECustomerKey = r.opts.SrcSSECustomerKey
+	}
+	if err := validateUploadPartCopySources(srcSSECustomerKey); err != nil {
+		return err
+	}
+
+	createInput := &s3.CreateMultipartUploadInput{Bucket: &dstBucket, Key: &dstKey}
+	applyDstEncryption(createInput, r.opts)
+	applyChecksumAlgorithm(createInput, r.opts)
+
+	createStart := time.Now()
+	var created *s3.CreateMultipartUploadOutput
+	err := withRetry(ctx, r.opts, "CreateMultipartUpload", func(attemptCtx context.Context) error {
+		var createErr error
+		created, createErr = r.client.CreateMultipartUpload(attemptCtx, createInput)
+		return createErr
+	})
+	metricsFor(r.opts).observe("CreateMultipartUpload", createStart, 0, err)
+	if err != nil {
+		return fmt.Errorf("s3tar: CreateMultipartUpload for %s/%s: %w", dstBucket, dstKey, err)
+	}
+
+	copySource := fmt.Sprintf("%s/%s", r.bucket, r.key)
+	copyRange := fmt.Sprintf("bytes=%d-%d", e.Offset, e.Offset+e.Size-1)
+	copyInput := &s3.UploadPartCopyInput{
+		Bucket:          &dstBucket,
+		Key:             &dstKey,
+		UploadId:        created.UploadId,
+		PartNumber:      aws.Int32(1),
+		CopySource:      &copySource,
+		CopySourceRange: &copyRange,
+	}
+	applyDstEncryption(copyInput, r.opts)
+
+	metricsFor(r.opts).partStarted()
+	copyStart := time.Now()
+	var part *s3.UploadPartCopyOutput
+	err = withRetry(ctx, r.opts, "UploadPartCopy", func(attemptCtx context.Context) error {
+		var copyErr error
+		part, copyErr = r.client.UploadPartCopy(attemptCtx, copyInput)
+		return copyErr
+	})
+	metricsFor(r.opts).observe("UploadPartCopy", copyStart, e.Size, err)
+	metricsFor(r.opts).partFinished()
+	if err != nil {
+		abortStart := time.Now()
+		abortErr := withRetry(ctx, r.opts, "AbortMultipartUpload", func(attemptCtx context.Context) error {
+			_, abortErr := r.client.AbortMultipartUpload(attemptCtx, &s3.AbortMultipartUploadInput{
+				Bucket: &dstBucket, Key: &dstKey, UploadId: created.UploadId,
+			})
+			return abortErr
+		})
+		metricsFor(r.opts).observe("AbortMultipartUpload", abortStart, 0, abortErr)
+		return fmt.Errorf("s3tar: UploadPartCopy for %s/%s: %w", dstBucket, dstKey, err)
+	}
+
+	completeStart := time.Now()
+	err = withRetry(ctx, r.opts, "CompleteMultipartUpload", func(attemptCtx context.Context) error {
+		_, completeErr := r.client.CompleteMultipartUpload(attemptCtx, &s3.CompleteMultipartUploadInput{
+			Bucket:   &dstBucket,
+			Key:      &dstKey,
+			UploadId: created.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{
+				Parts: []types.CompletedPart{{ETag: part.CopyPartResult.ETag, PartNumber: aws.Int32(1)}},
+			},
+		})
+		return completeErr
+	})
+	metricsFor(r.opts).observe("CompleteMultipartUpload", completeStart, 0, err)
+	if err != nil {
+		return fmt.Errorf("s3tar: CompleteMultipartUpload for %s/%s: %w", dstBucket, dstKey, err)
+	}
+	metricsFor(r.opts).addBytesCopied(e.Size)
+	return nil
+}
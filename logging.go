@@ -0,0 +1,56 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"log"
+)
+
+const contextKeyLogger = contextKey("logger")
+
+// Logger is a leveled, structured logging interface. Library users can
+// implement it to route s3tar's diagnostics to zap, zerolog, or anything
+// else, and to correlate log lines with a per-archive request ID. Attach
+// an implementation to a context with WithLogger; GetLogger falls back to
+// a plain stdlib logger when none was set.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// WithLogger attaches l to ctx, alongside the S3 client stored under
+// contextKeyS3Client, so it can be retrieved with GetLogger anywhere
+// ctx is threaded.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKeyLogger, l)
+}
+
+// GetLogger returns the Logger attached to ctx via WithLogger, or a
+// default logger backed by the standard library's log package.
+func GetLogger(ctx context.Context) Logger {
+	if l, ok := ctx.Value(contextKeyLogger).(Logger); ok && l != nil {
+		return l
+	}
+	return stdLogger{}
+}
+
+// stdLogger is the default Logger, used when the caller hasn't supplied
+// one of its own.
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...interface{}) { log.Printf("DEBUG "+format, args...) }
+func (stdLogger) Infof(format string, args ...interface{})  { log.Printf("INFO "+format, args...) }
+func (stdLogger) Warnf(format string, args ...interface{})  { log.Printf("WARN "+format, args...) }
+func (stdLogger) Errorf(format string, args ...interface{}) { log.Printf("ERROR "+format, args...) }
+
+// Fatalf logs a fatal error through the ctx's Logger and terminates the
+// process, mirroring the stdlib's log.Fatalf but routed through the
+// structured logger so it carries the same request correlation.
+func Fatalf(ctx context.Context, format string, args ...interface{}) {
+	GetLogger(ctx).Errorf(format, args...)
+	log.Fatalf(format, args...)
+}
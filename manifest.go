@@ -4,40 +4,68 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
-	"encoding/csv"
-	"fmt"
-	"log"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
-func buildManifest(ctx context.Context, objectList []*S3Obj) (*S3Obj, *S3Obj) {
+func buildManifest(ctx context.Context, opts *S3TarS3Options, objectList []*S3Obj) (*S3Obj, *S3Obj) {
+
+	if err := ensureMemberChecksums(ctx, opts, objectList); err != nil {
+		Fatalf(ctx, "computing member checksums: %s", err)
+	}
 
 	headers := processHeaders(ctx, objectList, false)
-	manifest := _buildManifest(ctx, headers, objectList)
+	codec := codecForFormat(manifestFormatFor(opts))
+	manifest := _buildManifest(ctx, codec, headers, objectList)
 
 	// Build a header with the original data
 	manifestObj := NewS3Obj()
-	manifestObj.Key = aws.String("manifest.csv")
-	manifestObj.AddData(manifest.Bytes())
+	manifestObj.Key = aws.String(manifestKeyFor(codec.Format()))
+	manifestObj.AddDataWithChecksum(manifest.Bytes(), checksumAlgorithmFor(opts))
 	manifestHeader := buildHeader(manifestObj, nil, false)
 	manifestHeader.Bucket = objectList[0].Bucket
 	manifestObj.Bucket = objectList[0].Bucket
 
-	log.Printf("XXX %s TTT %s", manifestObj.Bucket, manifestHeader.Bucket)
+	GetLogger(ctx).Debugf("built %s manifest for %s/%s with %d members", codec.Format(), manifestObj.Bucket, *manifestObj.Key, len(objectList))
 
 	return manifestObj, &manifestHeader
 }
 
-func _buildManifest(ctx context.Context, headers []*S3Obj, objectList []*S3Obj) *bytes.Buffer {
+func manifestFormatFor(opts *S3TarS3Options) ManifestFormat {
+	if opts == nil {
+		return ManifestFormatCSV
+	}
+	return opts.ManifestFormat
+}
+
+// manifestKeyFor names the manifest member after the codec producing it,
+// so a plain `tar tf` listing still hints at how to parse it.
+func manifestKeyFor(f ManifestFormat) string {
+	switch f {
+	case ManifestFormatNDJSON:
+		return "manifest.ndjson"
+	case ManifestFormatSortedIndex:
+		return "manifest.idx"
+	default:
+		return "manifest.csv"
+	}
+}
+
+func _buildManifest(ctx context.Context, codec ManifestCodec, headers []*S3Obj, objectList []*S3Obj) *bytes.Buffer {
 
 	var currLocation int64 = 0
-	data := createCSVManifest(currLocation, headers, objectList)
+	data, err := createManifest(codec, currLocation, headers, objectList)
+	if err != nil {
+		Fatalf(ctx, "encoding manifest: %s", err)
+	}
 	estimate := int64(data.Len())
 
 	for {
-		data = createCSVManifest(int64(estimate), headers, objectList)
+		data, err = createManifest(codec, int64(estimate), headers, objectList)
+		if err != nil {
+			Fatalf(ctx, "encoding manifest: %s", err)
+		}
 		l := int64(data.Len())
 		lp := l + findPadding(l)
 		if lp >= estimate {
@@ -50,36 +78,41 @@ func _buildManifest(ctx context.Context, headers []*S3Obj, objectList []*S3Obj)
 	return data
 }
 
-func createCSVManifest(offset int64, headers []*S3Obj, objectList []*S3Obj) *bytes.Buffer {
+// createManifest copies each member's Checksum straight from objectList
+// onto its ManifestEntry. buildManifest populates objectList[i].Checksum
+// via ensureMemberChecksums before calling this, reading each member's
+// existing x-amz-checksum-* value back from S3 with HeadObject; entries
+// still come out "" for a member that predates checksum support, or that
+// was uploaded without one.
+func createManifest(codec ManifestCodec, offset int64, headers []*S3Obj, objectList []*S3Obj) (*bytes.Buffer, error) {
 	var currLocation int64 = offset + 512
 	currLocation = currLocation + findPadding(currLocation)
-	buf := bytes.Buffer{}
-	manifest := [][]string{}
+	entries := make([]ManifestEntry, len(objectList))
 
 	for i := 0; i < len(objectList); i++ {
 		currLocation += headers[i].Size
-		// log.Printf("%d -> %d -> %s", currLocation, objectList[i].Size, *objectList[i].Key)
-		line := []string{}
-		line = append(line,
-			*objectList[i].Key,
-			fmt.Sprintf("%d", currLocation),
-			fmt.Sprintf("%d", objectList[i].Size),
-			*objectList[i].ETag)
-		manifest = append(manifest, line)
+		entries[i] = ManifestEntry{
+			Key:      *objectList[i].Key,
+			Offset:   currLocation,
+			Size:     objectList[i].Size,
+			ETag:     *objectList[i].ETag,
+			Checksum: objectList[i].Checksum,
+		}
 		currLocation += objectList[i].Size
 	}
-	cw := csv.NewWriter(&buf)
-	cw.WriteAll(manifest)
-	cw.Flush()
 
-	return &buf
+	data, err := codec.Encode(entries)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(data), nil
 }
 
-func buildFirstPart(csvData []byte) *S3Obj {
+func buildFirstPart(ctx context.Context, name string, csvData []byte) *S3Obj {
 	buf := &bytes.Buffer{}
 	tw := tar.NewWriter(buf)
 	hdr := &tar.Header{
-		Name:       "manifest.csv",
+		Name:       name,
 		Mode:       0600,
 		Size:       int64(len(csvData)),
 		ModTime:    time.Now(),
@@ -89,7 +122,7 @@ func buildFirstPart(csvData []byte) *S3Obj {
 	}
 	buf.Write(pad)
 	if err := tw.WriteHeader(hdr); err != nil {
-		log.Fatal(err)
+		Fatalf(ctx, "writing manifest tar header: %s", err)
 	}
 	tw.Flush()
 	buf.Write(csvData)
@@ -104,4 +137,4 @@ func buildFirstPart(csvData []byte) *S3Obj {
 	endPadding := NewS3Obj()
 	endPadding.AddData(buf.Bytes())
 	return endPadding
-}
\ No newline at end of file
+}
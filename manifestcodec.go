@@ -0,0 +1,328 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ManifestFormat selects the on-disk table-of-contents layout buildManifest
+// writes and Reader reads back.
+type ManifestFormat string
+
+const (
+	// ManifestFormatCSV is the original, human-readable layout: one
+	// "key,offset,size,etag[,checksum]" row per member.
+	ManifestFormatCSV ManifestFormat = "csv"
+	// ManifestFormatNDJSON is one JSON object per line, easier to stream
+	// or consume from languages without a CSV decoder handy.
+	ManifestFormatNDJSON ManifestFormat = "ndjson"
+	// ManifestFormatSortedIndex is a binary, key-sorted layout of
+	// fixed-width (offset,size,keyOffset,keyLen,metaOffset,metaLen,origIndex)
+	// records followed by a string heap, letting Reader.Open binary-search
+	// a member's range without building an entry for every member.
+	ManifestFormatSortedIndex ManifestFormat = "sorted-index"
+)
+
+// ManifestEntry is one member's table-of-contents row: its name, byte
+// range within the assembled tar, S3 ETag, and its additional checksum,
+// which buildManifest fills in when S3TarS3Options.ChecksumAlgorithm is
+// set and S3 has one recorded for the member (see createManifest).
+type ManifestEntry struct {
+	Key      string
+	Offset   int64
+	Size     int64
+	ETag     string
+	Checksum string
+}
+
+// ManifestCodec encodes a table of contents to bytes and decodes it back.
+// Select one with S3TarS3Options.ManifestFormat; codecForFormat resolves
+// the default (CSV) when the option is left empty.
+type ManifestCodec interface {
+	Format() ManifestFormat
+	Encode(entries []ManifestEntry) ([]byte, error)
+	Decode(data []byte) ([]ManifestEntry, error)
+	// Lookup finds the single entry named name without necessarily
+	// decoding the whole manifest. sortedIndexManifestCodec binary-searches
+	// its sorted records and builds only the matching entry, though it
+	// still reads the whole fixed-width record array first;
+	// csvManifestCodec and ndjsonManifestCodec have no index to search, so
+	// they decode fully and scan linearly.
+	Lookup(data []byte, name string) (ManifestEntry, bool, error)
+}
+
+// codecForFormat resolves f to its ManifestCodec, defaulting to CSV.
+func codecForFormat(f ManifestFormat) ManifestCodec {
+	switch f {
+	case ManifestFormatNDJSON:
+		return ndjsonManifestCodec{}
+	case ManifestFormatSortedIndex:
+		return sortedIndexManifestCodec{}
+	default:
+		return csvManifestCodec{}
+	}
+}
+
+// csvManifestCodec is the original manifest.csv layout.
+type csvManifestCodec struct{}
+
+func (csvManifestCodec) Format() ManifestFormat { return ManifestFormatCSV }
+
+func (csvManifestCodec) Encode(entries []ManifestEntry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	cw := csv.NewWriter(buf)
+	for _, e := range entries {
+		row := []string{e.Key, fmt.Sprintf("%d", e.Offset), fmt.Sprintf("%d", e.Size), e.ETag}
+		if e.Checksum != "" {
+			row = append(row, e.Checksum)
+		}
+		if err := cw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c csvManifestCodec) Lookup(data []byte, name string) (ManifestEntry, bool, error) {
+	entries, err := c.Decode(data)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	for _, e := range entries {
+		if e.Key == name {
+			return e, true, nil
+		}
+	}
+	return ManifestEntry{}, false, nil
+}
+
+func (csvManifestCodec) Decode(data []byte) ([]ManifestEntry, error) {
+	cr := csv.NewReader(strings.NewReader(string(data)))
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("s3tar: parsing CSV manifest: %w", err)
+	}
+
+	entries := make([]ManifestEntry, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 4 {
+			return nil, fmt.Errorf("s3tar: CSV manifest row for %q has %d fields, want at least 4", row[0], len(row))
+		}
+		offset, err := StringToInt64(row[1])
+		if err != nil {
+			return nil, fmt.Errorf("s3tar: CSV manifest offset for %q: %w", row[0], err)
+		}
+		size, err := StringToInt64(row[2])
+		if err != nil {
+			return nil, fmt.Errorf("s3tar: CSV manifest size for %q: %w", row[0], err)
+		}
+		e := ManifestEntry{Key: row[0], Offset: offset, Size: size, ETag: row[3]}
+		if len(row) >= 5 {
+			e.Checksum = row[4]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ndjsonManifestCodec is a newline-delimited JSON table of contents.
+type ndjsonManifestCodec struct{}
+
+func (ndjsonManifestCodec) Format() ManifestFormat { return ManifestFormatNDJSON }
+
+type ndjsonManifestRow struct {
+	Key      string `json:"key"`
+	Offset   int64  `json:"offset"`
+	Size     int64  `json:"size"`
+	ETag     string `json:"etag"`
+	Checksum string `json:"sha256,omitempty"`
+}
+
+func (ndjsonManifestCodec) Encode(entries []ManifestEntry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, e := range entries {
+		row := ndjsonManifestRow{Key: e.Key, Offset: e.Offset, Size: e.Size, ETag: e.ETag, Checksum: e.Checksum}
+		if err := enc.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (c ndjsonManifestCodec) Lookup(data []byte, name string) (ManifestEntry, bool, error) {
+	entries, err := c.Decode(data)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	for _, e := range entries {
+		if e.Key == name {
+			return e, true, nil
+		}
+	}
+	return ManifestEntry{}, false, nil
+}
+
+func (ndjsonManifestCodec) Decode(data []byte) ([]ManifestEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var entries []ManifestEntry
+	for dec.More() {
+		var row ndjsonManifestRow
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("s3tar: parsing NDJSON manifest: %w", err)
+		}
+		entries = append(entries, ManifestEntry{
+			Key: row.Key, Offset: row.Offset, Size: row.Size, ETag: row.ETag, Checksum: row.Checksum,
+		})
+	}
+	return entries, nil
+}
+
+// sortedIndexManifestCodec is a binary layout: a 4-byte record count,
+// followed by one fixed-width record per member (sorted by key) holding
+// offset, size, a (keyOffset, keyLen) pointer into a trailing string heap
+// that also carries ETag and checksum as "etag\x00checksum", and the
+// member's position in the original (tar-member) order. The records
+// array is sorted by key so Lookup can binary-search it, comparing key
+// bytes straight out of the heap, without decoding every entry; Decode
+// still materializes the full table (e.g. for Reader.List), but restores
+// original order via that stored position so List() matches the other
+// codecs instead of returning alphabetical order.
+type sortedIndexManifestCodec struct{}
+
+func (sortedIndexManifestCodec) Format() ManifestFormat { return ManifestFormatSortedIndex }
+
+type sortedIndexRecord struct {
+	Offset  int64
+	Size    int64
+	KeyOff  uint32
+	KeyLen  uint32
+	MetaOff uint32
+	MetaLen uint32
+	OrigIdx uint32
+}
+
+const sortedIndexRecordSize = 8 + 8 + 4 + 4 + 4 + 4 + 4
+
+func (sortedIndexManifestCodec) Encode(entries []ManifestEntry) ([]byte, error) {
+	type indexed struct {
+		entry ManifestEntry
+		orig  uint32
+	}
+	items := make([]indexed, len(entries))
+	for i, e := range entries {
+		items[i] = indexed{entry: e, orig: uint32(i)}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].entry.Key < items[j].entry.Key })
+
+	var heap bytes.Buffer
+	records := make([]sortedIndexRecord, len(items))
+	for i, it := range items {
+		e := it.entry
+		keyOff := heap.Len()
+		heap.WriteString(e.Key)
+		metaOff := heap.Len()
+		heap.WriteString(e.ETag + "\x00" + e.Checksum)
+		records[i] = sortedIndexRecord{
+			Offset:  e.Offset,
+			Size:    e.Size,
+			KeyOff:  uint32(keyOff),
+			KeyLen:  uint32(len(e.Key)),
+			MetaOff: uint32(metaOff),
+			MetaLen: uint32(heap.Len() - metaOff),
+			OrigIdx: it.orig,
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.BigEndian, uint32(len(records))); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if err := binary.Write(buf, binary.BigEndian, r); err != nil {
+			return nil, err
+		}
+	}
+	buf.Write(heap.Bytes())
+	return buf.Bytes(), nil
+}
+
+// parseSortedIndex reads data's record array and returns it alongside the
+// trailing string heap the records' key/meta offsets point into, without
+// building any ManifestEntry values.
+func parseSortedIndex(data []byte) ([]sortedIndexRecord, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("s3tar: sorted-index manifest truncated")
+	}
+	r := bytes.NewReader(data)
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, nil, err
+	}
+
+	records := make([]sortedIndexRecord, count)
+	for i := range records {
+		if err := binary.Read(r, binary.BigEndian, &records[i]); err != nil {
+			return nil, nil, fmt.Errorf("s3tar: sorted-index manifest record %d: %w", i, err)
+		}
+	}
+	heapOff := len(data) - r.Len()
+	return records, data[heapOff:], nil
+}
+
+func entryFromSortedRecord(rec sortedIndexRecord, heap []byte) ManifestEntry {
+	key := string(heap[rec.KeyOff : rec.KeyOff+rec.KeyLen])
+	meta := string(heap[rec.MetaOff : rec.MetaOff+rec.MetaLen])
+	etag, checksum, _ := strings.Cut(meta, "\x00")
+	return ManifestEntry{Key: key, Offset: rec.Offset, Size: rec.Size, ETag: etag, Checksum: checksum}
+}
+
+func (sortedIndexManifestCodec) Decode(data []byte) ([]ManifestEntry, error) {
+	records, heap, err := parseSortedIndex(data)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ManifestEntry, len(records))
+	for _, rec := range records {
+		entries[rec.OrigIdx] = entryFromSortedRecord(rec, heap)
+	}
+	return entries, nil
+}
+
+// Lookup binary-searches the sorted records array, comparing key bytes
+// directly from the heap, and only builds a ManifestEntry for the record
+// that matches. It still parses every fixed-width record via
+// parseSortedIndex first, which is linear in the member count; only the
+// key comparisons are O(log n).
+func (sortedIndexManifestCodec) Lookup(data []byte, name string) (ManifestEntry, bool, error) {
+	records, heap, err := parseSortedIndex(data)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	key := []byte(name)
+	i := sort.Search(len(records), func(i int) bool {
+		rec := records[i]
+		return bytes.Compare(heap[rec.KeyOff:rec.KeyOff+rec.KeyLen], key) >= 0
+	})
+	if i >= len(records) {
+		return ManifestEntry{}, false, nil
+	}
+	rec := records[i]
+	if !bytes.Equal(heap[rec.KeyOff:rec.KeyOff+rec.KeyLen], key) {
+		return ManifestEntry{}, false, nil
+	}
+	return entryFromSortedRecord(rec, heap), true, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testManifestEntries() []ManifestEntry {
+	return []ManifestEntry{
+		{Key: "zebra.txt", Offset: 512, Size: 100, ETag: "etag-z", Checksum: "sum-z"},
+		{Key: "apple.txt", Offset: 612, Size: 200, ETag: "etag-a", Checksum: "sum-a"},
+		{Key: "mango.txt", Offset: 812, Size: 50, ETag: "etag-m", Checksum: ""},
+	}
+}
+
+func TestSortedIndexManifestCodecRoundTrip(t *testing.T) {
+	codec := sortedIndexManifestCodec{}
+	entries := testManifestEntries()
+
+	data, err := codec.Encode(entries)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, err := codec.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("Decode(Encode(entries)) = %+v, want original tar-member order %+v", got, entries)
+	}
+}
+
+func TestSortedIndexManifestCodecLookup(t *testing.T) {
+	codec := sortedIndexManifestCodec{}
+	entries := testManifestEntries()
+
+	data, err := codec.Encode(entries)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	for _, want := range entries {
+		got, ok, err := codec.Lookup(data, want.Key)
+		if err != nil {
+			t.Fatalf("Lookup(%q): %v", want.Key, err)
+		}
+		if !ok {
+			t.Fatalf("Lookup(%q): not found", want.Key)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Lookup(%q) = %+v, want %+v", want.Key, got, want)
+		}
+	}
+
+	if _, ok, err := codec.Lookup(data, "does-not-exist.txt"); err != nil || ok {
+		t.Errorf("Lookup(missing) = (ok=%v, err=%v), want (false, nil)", ok, err)
+	}
+}
+
+func TestManifestCodecsRoundTrip(t *testing.T) {
+	entries := testManifestEntries()
+	for _, codec := range []ManifestCodec{csvManifestCodec{}, ndjsonManifestCodec{}} {
+		data, err := codec.Encode(entries)
+		if err != nil {
+			t.Fatalf("%s Encode: %v", codec.Format(), err)
+		}
+		got, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("%s Decode: %v", codec.Format(), err)
+		}
+		if !reflect.DeepEqual(got, entries) {
+			t.Errorf("%s Decode(Encode(entries)) = %+v, want %+v", codec.Format(), got, entries)
+		}
+
+		found, ok, err := codec.Lookup(data, entries[1].Key)
+		if err != nil || !ok || !reflect.DeepEqual(found, entries[1]) {
+			t.Errorf("%s Lookup(%q) = (%+v, %v, %v), want (%+v, true, nil)", codec.Format(), entries[1].Key, found, ok, err, entries[1])
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors instrumenting the S3 operations
+// s3tar issues while assembling an archive. Attach one to
+// S3TarS3Options.Metrics; leave it nil to disable instrumentation.
+type Metrics struct {
+	Operations    *prometheus.CounterVec
+	Latency       *prometheus.HistogramVec
+	PayloadBytes  *prometheus.HistogramVec
+	PartsInFlight prometheus.Gauge
+	BytesCopied   prometheus.Counter
+}
+
+// NewMetrics builds s3tar's collectors and registers them against reg.
+// Pass a nil Registerer to build an unregistered Metrics, e.g. for use in
+// tests.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "s3tar",
+			Name:      "s3_operations_total",
+			Help:      "Count of S3 API calls issued by s3tar, by verb and error class.",
+		}, []string{"verb", "error_class"}),
+		Latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "s3tar",
+			Name:      "s3_operation_duration_seconds",
+			Help:      "Latency of S3 API calls issued by s3tar, by verb.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"verb"}),
+		PayloadBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "s3tar",
+			Name:      "s3_operation_payload_bytes",
+			Help:      "Size of request bodies handled by s3tar, by verb.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+		}, []string{"verb"}),
+		PartsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "s3tar",
+			Name:      "parts_in_flight",
+			Help:      "Number of multipart-upload parts currently being copied or uploaded.",
+		}),
+		BytesCopied: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "s3tar",
+			Name:      "bytes_copied_total",
+			Help:      "Cumulative bytes copied via UploadPartCopy.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.Operations, m.Latency, m.PayloadBytes, m.PartsInFlight, m.BytesCopied)
+	}
+	return m
+}
+
+// metricsFor returns opts.Metrics, tolerating a nil opts.
+func metricsFor(opts *S3TarS3Options) *Metrics {
+	if opts == nil {
+		return nil
+	}
+	return opts.Metrics
+}
+
+// errorClass buckets an S3 API error for the "error_class" metric label.
+func errorClass(err error) string {
+	if err == nil {
+		return "none"
+	}
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		switch ae.ErrorCode() {
+		case "NoSuchKey", "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			return "client"
+		case "Throttling", "RequestTimeout", "SlowDown", "ServiceUnavailable", "InternalError":
+			return "retryable"
+		}
+	}
+	return "unknown"
+}
+
+// observe records one completed S3 operation. It is nil-receiver safe so
+// call sites don't need to special-case a disabled Metrics.
+func (m *Metrics) observe(verb string, start time.Time, payloadBytes int64, err error) {
+	if m == nil {
+		return
+	}
+	m.Operations.WithLabelValues(verb, errorClass(err)).Inc()
+	m.Latency.WithLabelValues(verb).Observe(time.Since(start).Seconds())
+	if payloadBytes > 0 {
+		m.PayloadBytes.WithLabelValues(verb).Observe(float64(payloadBytes))
+	}
+}
+
+// addBytesCopied increments BytesCopied, tolerating a nil Metrics.
+func (m *Metrics) addBytesCopied(n int64) {
+	if m == nil {
+		return
+	}
+	m.BytesCopied.Add(float64(n))
+}
+
+// partStarted increments PartsInFlight, tolerating a nil Metrics. Callers
+// issuing a multipart-upload part copy/upload pair it with partFinished so
+// the gauge tracks only parts actually in progress.
+func (m *Metrics) partStarted() {
+	if m == nil {
+		return
+	}
+	m.PartsInFlight.Inc()
+}
+
+// partFinished decrements PartsInFlight, tolerating a nil Metrics.
+func (m *Metrics) partFinished() {
+	if m == nil {
+		return
+	}
+	m.PartsInFlight.Dec()
+}
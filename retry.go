@@ -0,0 +1,150 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+const (
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// RetryClassification is the outcome of RetryPolicy.Classify for a given
+// error: whether the call that produced it is worth retrying.
+type RetryClassification int
+
+const (
+	// RetryTerminal means the error will never succeed on retry (4xx
+	// auth failures, NoSuchKey, validation errors, ...).
+	RetryTerminal RetryClassification = iota
+	// RetryRetryable means the error is transient (throttling, 5xx,
+	// connection reset, timeout, ...) and a subsequent attempt may
+	// succeed.
+	RetryRetryable
+)
+
+// RetryPolicy classifies errors and paces backoff between attempts.
+// Implement your own to intercept and log every retry, or to change the
+// classification/backoff strategy; the default used when
+// S3TarS3Options.RetryPolicy is nil is exponentialBackoffPolicy.
+type RetryPolicy interface {
+	Classify(err error) RetryClassification
+	Backoff(attempt int) time.Duration
+}
+
+// exponentialBackoffPolicy is capped exponential backoff with full jitter,
+// as described in https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+type exponentialBackoffPolicy struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+func (p exponentialBackoffPolicy) Classify(err error) RetryClassification {
+	if err == nil {
+		return RetryTerminal
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return RetryRetryable
+	}
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		switch ae.ErrorCode() {
+		case "Throttling", "ThrottlingException", "RequestTimeout", "SlowDown",
+			"ServiceUnavailable", "InternalError", "RequestTimeTooSkewed":
+			return RetryRetryable
+		case "NoSuchKey", "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch",
+			"InvalidArgument", "NoSuchBucket":
+			return RetryTerminal
+		}
+	}
+	// Connection resets and other I/O errors surface as plain errors
+	// rather than smithy.APIError; treat anything unrecognized as
+	// retryable so a flaky link doesn't kill an hours-long assembly.
+	return RetryRetryable
+}
+
+func (p exponentialBackoffPolicy) Backoff(attempt int) time.Duration {
+	initial, ceiling := p.initial, p.max
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if ceiling <= 0 {
+		ceiling = defaultMaxBackoff
+	}
+	backoffCap := initial << attempt
+	if backoffCap <= 0 || backoffCap > ceiling { // overflow or past the ceiling
+		backoffCap = ceiling
+	}
+	return time.Duration(rand.Int63n(int64(backoffCap) + 1))
+}
+
+// retryPolicyFor returns opts.RetryPolicy, or the default built from
+// opts.InitialBackoff/MaxBackoff when the caller didn't supply one.
+func retryPolicyFor(opts *S3TarS3Options) RetryPolicy {
+	if opts != nil && opts.RetryPolicy != nil {
+		return opts.RetryPolicy
+	}
+	var initial, max time.Duration
+	if opts != nil {
+		initial, max = opts.InitialBackoff, opts.MaxBackoff
+	}
+	return exponentialBackoffPolicy{initial: initial, max: max}
+}
+
+func maxAttemptsFor(opts *S3TarS3Options) int {
+	if opts != nil && opts.MaxAttempts > 0 {
+		return int(opts.MaxAttempts)
+	}
+	return defaultMaxAttempts
+}
+
+// withRetry runs fn, retrying per the options' RetryPolicy on retryable
+// errors, with a per-attempt timeout from opts.RequestTimeout (when set).
+// verb identifies the S3 operation for OnRetry callbacks and logging.
+func withRetry(ctx context.Context, opts *S3TarS3Options, verb string, fn func(ctx context.Context) error) error {
+	policy := retryPolicyFor(opts)
+	attempts := maxAttemptsFor(opts)
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		attemptCtx := ctx
+		var cancel context.CancelFunc
+		if opts != nil && opts.RequestTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.RequestTimeout)
+		}
+		err = fn(attemptCtx)
+		if cancel != nil {
+			cancel()
+		}
+		if err == nil {
+			return nil
+		}
+		if policy.Classify(err) != RetryRetryable || attempt == attempts-1 {
+			return err
+		}
+
+		wait := policy.Backoff(attempt)
+		GetLogger(ctx).Warnf("%s attempt %d/%d failed, retrying in %s: %v", verb, attempt+1, attempts, wait, err)
+		if opts != nil && opts.OnRetry != nil {
+			opts.OnRetry(verb, attempt+1, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3tar
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestExponentialBackoffPolicyClassify(t *testing.T) {
+	policy := exponentialBackoffPolicy{}
+
+	tests := []struct {
+		name string
+		err  error
+		want RetryClassification
+	}{
+		{"nil error", nil, RetryTerminal},
+		{"deadline exceeded", context.DeadlineExceeded, RetryRetryable},
+		{"throttling", &smithy.GenericAPIError{Code: "Throttling"}, RetryRetryable},
+		{"service unavailable", &smithy.GenericAPIError{Code: "ServiceUnavailable"}, RetryRetryable},
+		{"no such key", &smithy.GenericAPIError{Code: "NoSuchKey"}, RetryTerminal},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, RetryTerminal},
+		{"unrecognized API error", &smithy.GenericAPIError{Code: "SomeNewError"}, RetryRetryable},
+		{"plain I/O error", errors.New("connection reset by peer"), RetryRetryable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Classify(tt.err); got != tt.want {
+				t.Errorf("Classify(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffPolicyBackoffStaysWithinBounds(t *testing.T) {
+	policy := exponentialBackoffPolicy{initial: 100 * time.Millisecond, max: time.Second}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		for i := 0; i < 50; i++ {
+			wait := policy.Backoff(attempt)
+			if wait < 0 || wait > policy.max {
+				t.Fatalf("Backoff(%d) = %s, want within [0, %s]", attempt, wait, policy.max)
+			}
+		}
+	}
+}
+
+func TestExponentialBackoffPolicyBackoffDefaults(t *testing.T) {
+	policy := exponentialBackoffPolicy{}
+	if wait := policy.Backoff(0); wait < 0 || wait > defaultMaxBackoff {
+		t.Errorf("Backoff(0) with zero-value policy = %s, want within [0, %s]", wait, defaultMaxBackoff)
+	}
+}
+
+func TestWithRetrySucceedsAfterRetryableErrors(t *testing.T) {
+	attempts := 0
+	opts := &S3TarS3Options{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	err := withRetry(context.Background(), opts, "TestOp", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return &smithy.GenericAPIError{Code: "Throttling"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestWithRetryStopsOnTerminalError(t *testing.T) {
+	attempts := 0
+	terminal := &smithy.GenericAPIError{Code: "NoSuchKey"}
+	err := withRetry(context.Background(), nil, "TestOp", func(ctx context.Context) error {
+		attempts++
+		return terminal
+	})
+	if !errors.Is(err, terminal) {
+		t.Errorf("withRetry error = %v, want %v", err, terminal)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (no retry on a terminal error)", attempts)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"regexp"
 	"strconv"
@@ -50,6 +49,67 @@ type S3TarS3Options struct {
 	EndpointUrl        string
 	TarFormat          string
 	ExternalToc        string
+
+	// DstStorageClass is applied to the PutObject/CreateMultipartUpload
+	// requests that write the assembled archive and its manifest, e.g.
+	// types.StorageClassStandardIa or types.StorageClassDeepArchive.
+	// Left empty, S3 defaults to STANDARD.
+	DstStorageClass types.StorageClass
+
+	// Destination encryption. DstSSEType selects SSE-S3 (AES256) or
+	// SSE-KMS (aws:kms); leave empty and set DstSSECustomerKey for SSE-C.
+	DstSSEType                 types.ServerSideEncryption
+	DstSSEKMSKeyId             string
+	DstSSEKMSEncryptionContext string
+	DstSSECustomerAlgorithm    string
+	DstSSECustomerKey          string
+	DstSSECustomerKeyMD5       string
+
+	// SrcSSECustomerKey (with SrcSSECustomerAlgorithm/KeyMD5) is required
+	// to read back intermediate parts that were uploaded with SSE-C.
+	SrcSSECustomerAlgorithm string
+	SrcSSECustomerKey       string
+	SrcSSECustomerKeyMD5    string
+
+	// Metrics instruments every S3 call s3tar issues. Leave nil to run
+	// without instrumentation, or set it to NewMetrics(reg) to publish
+	// to a prometheus.Registerer.
+	Metrics *Metrics
+
+	// Retry/timeout tuning for every S3 call s3tar issues. Zero values
+	// fall back to defaultMaxAttempts/defaultInitialBackoff/defaultMaxBackoff.
+	// ConnectTimeout bounds the TCP/TLS handshake; it only takes effect on
+	// an *s3.Client built with NewS3Client(cfg, opts), since the SDK has
+	// no other hook to bound the dial itself.
+	MaxAttempts    uint
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	RequestTimeout time.Duration
+	ConnectTimeout time.Duration
+
+	// RetryPolicy overrides the default capped-exponential-backoff-with-
+	// jitter policy. OnRetry, when set, is called after each retryable
+	// failure that will be retried (not after the final attempt) so
+	// callers can log or count retries themselves.
+	RetryPolicy RetryPolicy
+	OnRetry     func(verb string, attempt int, err error)
+
+	// JobID and CheckpointStore make archive assembly resumable: when
+	// both are set, SaveCheckpoint persists progress under JobID and a
+	// later Resume(ctx, opts, JobID, objectList) reconciles it against S3
+	// to continue an interrupted job instead of restarting it.
+	JobID           string
+	CheckpointStore CheckpointStore
+
+	// ManifestFormat selects the table-of-contents layout buildManifest
+	// writes; empty defaults to ManifestFormatCSV.
+	ManifestFormat ManifestFormat
+
+	// ChecksumAlgorithm, when set, is recorded against every member in
+	// the manifest and passed to S3 as the destination archive's
+	// x-amz-checksum-algorithm so the assembled object carries a
+	// verifiable full-object checksum.
+	ChecksumAlgorithm ChecksumAlgorithm
 }
 
 func findMinMaxPartRange(objectSize int64) (int64, int64, int64) {
@@ -152,6 +212,10 @@ type S3Obj struct {
 	PartNum          int
 	Data             []byte
 	NoHeaderRequired bool
+	// Checksum holds the additional checksum recorded by AddDataWithChecksum
+	// or read back from S3 by ensureMemberChecksums, base64-encoded in the
+	// same form S3's x-amz-checksum-* headers use.
+	Checksum string
 }
 
 func (s *S3Obj) AddData(data []byte) {
@@ -179,10 +243,6 @@ func findPadding(offset int64) (n int64) {
 	return -offset & (blockSize - 1)
 }
 
-type Logger struct {
-	Level int
-}
-
 // ExtractBucketAndPath helper function to extract bucket and key from s3://bucket/prefix/key URLs
 func ExtractBucketAndPath(s3url string) (bucket string, path string) {
 	parts := extractS3.FindAllStringSubmatch(s3url, -1)
@@ -193,7 +253,7 @@ func ExtractBucketAndPath(s3url string) (bucket string, path string) {
 	return
 }
 
-func listAllObjects(ctx context.Context, client *s3.Client, Bucket, Prefix string) []*S3Obj {
+func listAllObjects(ctx context.Context, client *s3.Client, opts *S3TarS3Options, Bucket, Prefix string) []*S3Obj {
 	var objectList []types.Object
 	input := &s3.ListObjectsV2Input{
 		Bucket: &Bucket,
@@ -204,9 +264,14 @@ func listAllObjects(ctx context.Context, client *s3.Client, Bucket, Prefix strin
 		if !p.HasMorePages() {
 			break
 		}
-		output, err := p.NextPage(ctx)
+		var output *s3.ListObjectsV2Output
+		err := withRetry(ctx, opts, "ListObjectsV2", func(attemptCtx context.Context) error {
+			var pageErr error
+			output, pageErr = p.NextPage(attemptCtx)
+			return pageErr
+		})
 		if err != nil {
-			log.Print(err.Error())
+			GetLogger(ctx).Errorf("ListObjectsV2 %s/%s: %v", Bucket, Prefix, err)
 			break
 		}
 		objectList = append(objectList, output.Contents...)
@@ -237,20 +302,39 @@ func listAllObjects(ctx context.Context, client *s3.Client, Bucket, Prefix strin
 	return list
 }
 
-func putObject(ctx context.Context, svc *s3.Client, bucket, key string, data []byte) (*s3.PutObjectOutput, error) {
+func putObject(ctx context.Context, svc *s3.Client, opts *S3TarS3Options, bucket, key string, data []byte) (*s3.PutObjectOutput, error) {
+	if err := validateEncryptionOptions(opts); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
 	input := &s3.PutObjectInput{
 		Bucket:        &bucket,
 		Key:           &key,
-		Body:          bytes.NewReader(data),
 		ContentLength: int64(len(data)),
 	}
-	return svc.PutObject(ctx, input)
+	applyDstEncryption(input, opts)
+	applyChecksumAlgorithm(input, opts)
+
+	var output *s3.PutObjectOutput
+	err := withRetry(ctx, opts, "PutObject", func(attemptCtx context.Context) error {
+		input.Body = bytes.NewReader(data)
+		var putErr error
+		output, putErr = svc.PutObject(attemptCtx, input)
+		return putErr
+	})
+	metricsFor(opts).observe("PutObject", start, int64(len(data)), err)
+	if err != nil {
+		GetLogger(ctx).Errorf("PutObject %s/%s: %v", bucket, key, err)
+	}
+	return output, err
 }
 
-func getObject(ctx context.Context, svc *s3.Client, bucket, key string) (io.ReadCloser, error) {
-	return getObjectRange(ctx, svc, bucket, key, 0, 0)
+func getObject(ctx context.Context, svc *s3.Client, opts *S3TarS3Options, bucket, key string) (io.ReadCloser, error) {
+	return getObjectRange(ctx, svc, opts, bucket, key, 0, 0)
 }
-func getObjectRange(ctx context.Context, svc *s3.Client, bucket, key string, start, end int64) (io.ReadCloser, error) {
+func getObjectRange(ctx context.Context, svc *s3.Client, opts *S3TarS3Options, bucket, key string, start, end int64) (io.ReadCloser, error) {
+	requestStart := time.Now()
 	params := &s3.GetObjectInput{
 		Key:    &key,
 		Bucket: &bucket,
@@ -259,39 +343,61 @@ func getObjectRange(ctx context.Context, svc *s3.Client, bucket, key string, sta
 		byteRange := fmt.Sprintf("bytes=%d-%d", start, end)
 		params.Range = &byteRange
 	}
-	output, err := svc.GetObject(ctx, params)
+	applySrcSSECustomerKey(params, opts)
+
+	var output *s3.GetObjectOutput
+	err := withRetry(ctx, opts, "GetObject", func(attemptCtx context.Context) error {
+		var getErr error
+		output, getErr = svc.GetObject(attemptCtx, params)
+		return getErr
+	})
+	metricsFor(opts).observe("GetObject", requestStart, 0, err)
 	if err != nil {
+		GetLogger(ctx).Errorf("GetObject %s/%s: %v", bucket, key, err)
+		if output == nil {
+			return nil, err
+		}
 		return output.Body, err
 	}
 	return output.Body, nil
 }
 
-func loadFile(ctx context.Context, svc *s3.Client, path string) (io.ReadCloser, error) {
+func loadFile(ctx context.Context, svc *s3.Client, opts *S3TarS3Options, path string) (io.ReadCloser, error) {
 	if strings.Contains(path, "s3://") {
 		bucket, key := ExtractBucketAndPath(path)
-		return getObject(ctx, svc, bucket, key)
+		return getObject(ctx, svc, opts, bucket, key)
 	} else {
 		return os.Open(path)
 	}
 }
 
 // DeleteAllMultiparts helper function to clear ALL MultipartUploads in a bucket. This will delete all incomplete (or in progress) MPUs for a bucket.
-func DeleteAllMultiparts(client *s3.Client, bucket string) error {
-	output, err := client.ListMultipartUploads(context.TODO(), &s3.ListMultipartUploadsInput{Bucket: &bucket})
+// Uploads still owned by a resumable job tracked in store are left alone;
+// pass a nil store to abort every incomplete upload unconditionally.
+func DeleteAllMultiparts(ctx context.Context, client *s3.Client, bucket string, store CheckpointStore) error {
+	owned, err := checkpointOwnedUploadIDs(ctx, store, bucket)
+	if err != nil {
+		return err
+	}
+
+	output, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: &bucket})
 	if err != nil {
 		return err
 	}
 	for _, upload := range output.Uploads {
-		log.Printf("Aborting %s", *upload.UploadId)
-		_, err := client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		if owned[*upload.UploadId] {
+			GetLogger(ctx).Infof("Skipping %s: owned by a resumable checkpoint", *upload.UploadId)
+			continue
+		}
+		GetLogger(ctx).Infof("Aborting %s", *upload.UploadId)
+		_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
 			Bucket:   aws.String(bucket),
 			Key:      upload.Key,
 			UploadId: upload.UploadId,
 		})
 		if err != nil {
-			log.Fatalf(err.Error())
+			Fatalf(ctx, err.Error())
 		}
-		// log.Printf("AbortedMultiUpload ok %s", r)
 	}
 	return nil
 }
@@ -305,6 +411,7 @@ func GetS3Client(ctx context.Context) *s3.Client {
 }
 
 func _deleteObjectList(ctx context.Context, opts *S3TarS3Options, objectList []*S3Obj) error {
+	start := time.Now()
 	client := GetS3Client(ctx)
 	objects := make([]types.ObjectIdentifier, len(objectList))
 	for i := 0; i < len(objectList); i++ {
@@ -319,12 +426,18 @@ func _deleteObjectList(ctx context.Context, opts *S3TarS3Options, objectList []*
 			Objects: objects,
 		},
 	}
-	response, err := client.DeleteObjects(ctx, params)
+	var response *s3.DeleteObjectsOutput
+	err := withRetry(ctx, opts, "DeleteObjects", func(attemptCtx context.Context) error {
+		var deleteErr error
+		response, deleteErr = client.DeleteObjects(attemptCtx, params)
+		return deleteErr
+	})
+	metricsFor(opts).observe("DeleteObjects", start, 0, err)
 	if err != nil {
 		return err
 	}
 	if len(response.Errors) > 0 {
-		log.Fatal("Error deleting objects")
+		Fatalf(ctx, "Error deleting %d of %d objects", len(response.Errors), len(objectList))
 	}
 	return nil
 